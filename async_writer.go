@@ -0,0 +1,109 @@
+package main
+
+const (
+	defaultConcurrentWriters = 1
+	defaultWriteAheadBlocks  = 1
+)
+
+// flushJob is a dirty buffer queued for background writeback. It carries
+// its own copy of the page so the worker can write it out even after the
+// caller goes on to reuse the originating Buffer.
+type flushJob struct {
+	blk  BlockID
+	page Page
+	lsn  LSN
+}
+
+// SetConcurrentWriters sets how many goroutines drain the background flush
+// queue. It must be called before the first flush; calling it once the
+// pool has started panics.
+func (bm *BufferManager) SetConcurrentWriters(n int) {
+	bm.ensureWritersNotStarted()
+	bm.concurrentWriters = n
+}
+
+// SetWriteAheadBlocks bounds how many flushes may be queued or in flight
+// at once, by sizing flushCh's buffer: once that many writes are
+// outstanding, the next flushAsync call blocks in the channel send until a
+// worker goroutine drains one, applying backpressure to whatever's
+// producing dirty pages faster than they can be written. It must be
+// called before the first flush.
+func (bm *BufferManager) SetWriteAheadBlocks(n int) {
+	bm.ensureWritersNotStarted()
+	bm.writeAheadBlocks = n
+}
+
+func (bm *BufferManager) ensureWritersNotStarted() {
+	if bm.flushCh != nil {
+		panic("buffer manager: writer pool already started; configure before the first flush")
+	}
+}
+
+// ensureWritersStarted lazily spins up the writer pool on the first
+// flush, sized per the (possibly just-configured) concurrentWriters and
+// writeAheadBlocks.
+func (bm *BufferManager) ensureWritersStarted() {
+	bm.startWorkers.Do(func() {
+		bm.flushCh = make(chan flushJob, bm.writeAheadBlocks)
+		for i := 0; i < bm.concurrentWriters; i++ {
+			go bm.runWriter()
+		}
+	})
+}
+
+func (bm *BufferManager) runWriter() {
+	for job := range bm.flushCh {
+		bm.writeJob(job)
+		bm.pending.Done()
+	}
+}
+
+// writeJob performs one background write, honoring the write-ahead-log
+// ordering constraint: the data page only reaches disk once the log
+// record describing it is durable.
+func (bm *BufferManager) writeJob(job flushJob) {
+	if bm.lm != nil {
+		bm.lm.flush(job.lsn)
+	}
+	if err := bm.fm.store.WriteBlock(job.blk, job.page.buf); err != nil {
+		bm.setErr(err)
+	}
+}
+
+// flushAsync copies b's page and hands the copy off to the background
+// writer pool, blocking only if writeAheadBlocks flushes are already
+// queued or in flight. b itself is safe to reuse as soon as this returns.
+func (bm *BufferManager) flushAsync(b *Buffer) {
+	bm.ensureWritersStarted()
+
+	cp := newPage(len(b.page.buf))
+	copy(cp.buf, b.page.buf)
+	b.dirty = false
+
+	bm.pending.Add(1)
+	bm.flushCh <- flushJob{blk: b.blk, page: cp, lsn: b.lsn}
+}
+
+// Sync waits for every queued or in-flight background flush to complete
+// and returns the first error any of them hit, clearing it so it isn't
+// reported twice.
+func (bm *BufferManager) Sync() error {
+	bm.pending.Wait()
+	return bm.takeErr()
+}
+
+func (bm *BufferManager) setErr(err error) {
+	bm.errMu.Lock()
+	defer bm.errMu.Unlock()
+	if bm.err == nil {
+		bm.err = err
+	}
+}
+
+func (bm *BufferManager) takeErr() error {
+	bm.errMu.Lock()
+	defer bm.errMu.Unlock()
+	err := bm.err
+	bm.err = nil
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import "sync"
+
+// Buffer pairs a Page with the bookkeeping a BufferManager needs to decide
+// when it's safe to evict: which block it currently holds, how many
+// clients have it pinned, and whether it's been modified since it was read
+// from disk. lsn is the LSN of the log record describing the most recent
+// modification, so the buffer manager can enforce write-ahead logging
+// before flushing the page.
+type Buffer struct {
+	page     Page
+	blk      BlockID
+	assigned bool
+	pins     int
+	dirty    bool
+	lsn      LSN
+}
+
+func newBuffer(blocksize int) *Buffer {
+	return &Buffer{page: newPage(blocksize)}
+}
+
+// setDirty flags the buffer as modified and records the LSN of the log
+// record describing the change, if any, so the manager knows to write the
+// page back (and flush the log first) before reusing or evicting it.
+func (b *Buffer) setDirty(lsn LSN) {
+	b.dirty = true
+	if lsn > b.lsn {
+		b.lsn = lsn
+	}
+}
+
+// BufferManager owns a fixed-size pool of page buffers and hands them out
+// to callers via pin/unpin. Once a BufferManager sits in front of a
+// FileManager, callers should mutate pages through the Buffer it returns
+// rather than calling the FileManager directly, so the pool's view of
+// what's pinned and what's dirty stays accurate. If lm is non-nil, the
+// manager flushes the log up through a page's lsn before writing the page
+// itself, honoring the write-ahead-log ordering constraint.
+//
+// Both eviction in pin and flushAll hand dirty buffers off to a background
+// pool of writer goroutines rather than writing them inline; see
+// async_writer.go.
+type BufferManager struct {
+	fm      *FileManager
+	lm      *LogManager
+	buffers []*Buffer
+	// clock is the index to resume scanning from on the next eviction, so
+	// repeated evictions don't all land on the same early buffer.
+	clock int
+
+	concurrentWriters int
+	writeAheadBlocks  int
+	startWorkers      sync.Once
+	flushCh           chan flushJob
+	pending           sync.WaitGroup
+	errMu             sync.Mutex
+	err               error
+}
+
+func newBufferManager(fm *FileManager, lm *LogManager, numBuffers int) *BufferManager {
+	buffers := make([]*Buffer, numBuffers)
+	for i := range buffers {
+		buffers[i] = newBuffer(fm.blocksize)
+	}
+	return &BufferManager{
+		fm:                fm,
+		lm:                lm,
+		buffers:           buffers,
+		concurrentWriters: defaultConcurrentWriters,
+		writeAheadBlocks:  defaultWriteAheadBlocks,
+	}
+}
+
+// pin returns the Buffer holding blk, reading it from disk into a free
+// frame if it isn't already resident, and increments its pin count so it
+// can't be evicted out from under the caller. Every pin must be matched by
+// a later unpin. Background flush errors from a prior flushAll are
+// reported here, since pin is how callers start their next write.
+func (bm *BufferManager) pin(blk BlockID) *Buffer {
+	if err := bm.takeErr(); err != nil {
+		panic(err)
+	}
+
+	for _, b := range bm.buffers {
+		if b.assigned && b.blk == blk {
+			b.pins++
+			return b
+		}
+	}
+
+	b := bm.chooseUnpinnedBuffer()
+	if b == nil {
+		panic("buffer manager: no unpinned buffers available")
+	}
+	if b.dirty {
+		// flushAsync copies b's page out before returning, so it's safe to
+		// start overwriting b.page with the new block below right away;
+		// the old contents reach disk through the same bounded background
+		// pool flushAll uses, rather than blocking this pin on disk I/O.
+		bm.flushAsync(b)
+	}
+	bm.fm.read(blk, b.page)
+	b.blk = blk
+	b.assigned = true
+	b.pins = 1
+	return b
+}
+
+// unpin decrements the buffer's pin count. Once it reaches zero the buffer
+// becomes eligible for eviction.
+func (bm *BufferManager) unpin(b *Buffer) {
+	if b.pins == 0 {
+		panic("buffer manager: unpin of a buffer that isn't pinned")
+	}
+	b.pins--
+}
+
+// chooseUnpinnedBuffer scans the pool for a frame with no pins, starting
+// from clock and wrapping around, so eviction pressure spreads across the
+// pool instead of always landing on buffer 0.
+func (bm *BufferManager) chooseUnpinnedBuffer() *Buffer {
+	n := len(bm.buffers)
+	for i := 0; i < n; i++ {
+		idx := (bm.clock + i) % n
+		if bm.buffers[idx].pins == 0 {
+			bm.clock = (idx + 1) % n
+			return bm.buffers[idx]
+		}
+	}
+	return nil
+}
+
+// flushAll queues every dirty buffer in the pool for background writeback,
+// regardless of pin count, then waits for the pipeline to drain. It
+// returns the first error encountered by any of the writes.
+func (bm *BufferManager) flushAll() error {
+	for _, b := range bm.buffers {
+		if b.dirty {
+			bm.flushAsync(b)
+		}
+	}
+	return bm.Sync()
+}
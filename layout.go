@@ -0,0 +1,53 @@
+package main
+
+// Layout computes, once per Schema, the byte offset of each field within a
+// record and the total slot size, so RecordPage doesn't redo that
+// arithmetic on every access.
+type Layout struct {
+	schema   *Schema
+	offsets  map[string]int32
+	slotsize int32
+}
+
+func newLayout(schema *Schema) *Layout {
+	offsets := make(map[string]int32)
+	pos := int32(int32size) // leading USED/EMPTY flag
+	for _, name := range schema.fieldNames() {
+		offsets[name] = pos
+		pos += fieldLengthInBytes(schema, name)
+	}
+	return &Layout{schema: schema, offsets: offsets, slotsize: pos}
+}
+
+// fieldLengthInBytes returns how much room name's value takes up on a
+// page, matching the Page primitive used to store it: VARCHAR is stored
+// as a length-prefixed byte slice (see Page.setBytes), the rest as
+// fixed-width values.
+func fieldLengthInBytes(schema *Schema, name string) int32 {
+	switch schema.fieldType(name) {
+	case INT32:
+		return int32size
+	case INT64:
+		return int64size
+	case FLOAT64:
+		return float64size
+	case BOOL:
+		return boolsize
+	case TIMESTAMP:
+		return int64size // stored as a Unix timestamp
+	case VARCHAR:
+		return int32size + int32(schema.length(name))
+	default:
+		panic("layout: unknown field type")
+	}
+}
+
+// offset returns name's byte offset within a slot, panicking if name isn't
+// in the underlying schema rather than silently returning 0 (the zero
+// value for a missing map entry would otherwise alias the USED/EMPTY flag).
+func (l *Layout) offset(name string) int32 {
+	if !l.schema.hasField(name) {
+		panic("layout: no such field " + name)
+	}
+	return l.offsets[name]
+}
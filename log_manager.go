@@ -0,0 +1,147 @@
+package main
+
+import "sync"
+
+// LSN (log sequence number) identifies a log record by its position in the
+// sequence of records appended so far. The first record appended is LSN 1;
+// 0 means "no record".
+type LSN int
+
+// LogManager appends variable-length records to a dedicated log file.
+// Records are packed backwards within a page: each new record is written
+// just before the previous one, growing from the end of the page towards
+// the beginning, with offset 0 holding a "boundary" int pointing at the
+// start of the earliest record currently in the page. That layout is what
+// lets iterator walk the log newest-record-first without an index.
+//
+// mu guards logpage/currentBlk/latestLSN/lastSavedLSN, since flush is
+// called both by whatever goroutine appends log records and, via
+// BufferManager, by background writer goroutines flushing dirty data
+// pages concurrently.
+type LogManager struct {
+	mu           sync.Mutex
+	fm           *FileManager
+	logfile      string
+	logpage      Page
+	currentBlk   BlockID
+	latestLSN    LSN
+	lastSavedLSN LSN
+}
+
+func newLogManager(fm *FileManager, logfile string) *LogManager {
+	lm := &LogManager{
+		fm:      fm,
+		logfile: logfile,
+		logpage: newPage(fm.blocksize),
+	}
+
+	size := fm.length(logfile)
+	if size == 0 {
+		lm.currentBlk = lm.appendNewBlock()
+	} else {
+		lm.currentBlk = newBlockID(logfile, size-1)
+		fm.read(lm.currentBlk, lm.logpage)
+	}
+	return lm
+}
+
+// appendNewBlock adds a fresh block to the log file and sets its boundary
+// to point past the end of the page, since it holds no records yet.
+func (lm *LogManager) appendNewBlock() BlockID {
+	blk := lm.fm.append(lm.logfile)
+	lm.logpage.setInt(0, int32(lm.fm.blocksize))
+	lm.fm.write(blk, lm.logpage)
+	return blk
+}
+
+// append adds record to the log, starting a new block first if there isn't
+// room left in the current one, and returns its LSN. The record isn't
+// guaranteed to be on disk until flush is called with an LSN at least this
+// high.
+func (lm *LogManager) append(record []byte) LSN {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	boundary := lm.logpage.getInt(0)
+	bytesNeeded := int32(len(record)) + int32size
+
+	if boundary-bytesNeeded < int32size {
+		lm.flushCurrentPageLocked()
+		lm.currentBlk = lm.appendNewBlock()
+		boundary = lm.logpage.getInt(0)
+	}
+
+	recpos := boundary - bytesNeeded
+	lm.logpage.setBytes(recpos, record)
+	lm.logpage.setInt(0, recpos)
+	lm.latestLSN++
+	return lm.latestLSN
+}
+
+// flush writes the current log page to disk if it might hold a record up
+// to and including lsn that hasn't been saved yet. The buffer manager
+// calls this with a data page's pageLSN before writing that page out, so
+// the log record describing a change always reaches disk before the
+// change itself does. Background writer goroutines call this concurrently
+// with foreground append calls, so it takes mu like every other method.
+func (lm *LogManager) flush(lsn LSN) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lsn > lm.lastSavedLSN {
+		lm.flushCurrentPageLocked()
+	}
+}
+
+// flushCurrentPageLocked must be called with mu held.
+func (lm *LogManager) flushCurrentPageLocked() {
+	lm.fm.write(lm.currentBlk, lm.logpage)
+	lm.lastSavedLSN = lm.latestLSN
+}
+
+// iterator flushes any unwritten records and returns a LogIterator that
+// walks the log from the most recently appended record back to the
+// oldest.
+func (lm *LogManager) iterator() *LogIterator {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.flushCurrentPageLocked()
+	return newLogIterator(lm.fm, lm.currentBlk)
+}
+
+// LogIterator reads log records from newest to oldest, crossing page
+// boundaries (towards lower block numbers) as it exhausts each one.
+type LogIterator struct {
+	fm         *FileManager
+	blk        BlockID
+	page       Page
+	currentPos int32
+}
+
+func newLogIterator(fm *FileManager, blk BlockID) *LogIterator {
+	it := &LogIterator{fm: fm, page: newPage(fm.blocksize)}
+	it.moveToBlock(blk)
+	return it
+}
+
+func (it *LogIterator) moveToBlock(blk BlockID) {
+	it.fm.read(blk, it.page)
+	it.currentPos = it.page.getInt(0)
+	it.blk = blk
+}
+
+// hasNext reports whether there's another record to read, either later in
+// the current page or in an earlier block.
+func (it *LogIterator) hasNext() bool {
+	return it.currentPos < int32(it.fm.blocksize) || it.blk.blknum > 0
+}
+
+// next returns the next record and advances past it, moving to the
+// previous block first if the current one is exhausted.
+func (it *LogIterator) next() []byte {
+	if it.currentPos == int32(it.fm.blocksize) {
+		it.moveToBlock(newBlockID(it.blk.filename, it.blk.blknum-1))
+	}
+	record := it.page.getBytes(it.currentPos)
+	it.currentPos += int32size + int32(len(record))
+	return record
+}
@@ -0,0 +1,116 @@
+package main
+
+const (
+	flagEmpty int32 = 0
+	flagUsed  int32 = 1
+)
+
+// RecordPage stores fixed-size slots on top of a raw Page: each slot
+// begins with a USED/EMPTY flag followed by its fields packed according to
+// layout. It's the bridge between raw byte pages and a future table scan.
+type RecordPage struct {
+	page   Page
+	layout *Layout
+}
+
+func newRecordPage(page Page, layout *Layout) *RecordPage {
+	return &RecordPage{page: page, layout: layout}
+}
+
+func (rp *RecordPage) slotpos(slot int) int32 {
+	return int32(slot) * rp.layout.slotsize
+}
+
+func (rp *RecordPage) getInt(slot int, fieldName string) int32 {
+	return rp.page.getInt(rp.slotpos(slot) + rp.layout.offset(fieldName))
+}
+
+func (rp *RecordPage) setInt(slot int, fieldName string, val int32) {
+	rp.page.setInt(rp.slotpos(slot)+rp.layout.offset(fieldName), val)
+}
+
+func (rp *RecordPage) getString(slot int, fieldName string) string {
+	return rp.page.getString(rp.slotpos(slot) + rp.layout.offset(fieldName))
+}
+
+func (rp *RecordPage) setString(slot int, fieldName string, val string) {
+	rp.page.setString(rp.slotpos(slot)+rp.layout.offset(fieldName), val)
+}
+
+func (rp *RecordPage) getBool(slot int, fieldName string) bool {
+	return rp.page.getBool(rp.slotpos(slot) + rp.layout.offset(fieldName))
+}
+
+func (rp *RecordPage) setBool(slot int, fieldName string, val bool) {
+	rp.page.setBool(rp.slotpos(slot)+rp.layout.offset(fieldName), val)
+}
+
+func (rp *RecordPage) getInt64(slot int, fieldName string) int64 {
+	return rp.page.getInt64(rp.slotpos(slot) + rp.layout.offset(fieldName))
+}
+
+func (rp *RecordPage) setInt64(slot int, fieldName string, val int64) {
+	rp.page.setInt64(rp.slotpos(slot)+rp.layout.offset(fieldName), val)
+}
+
+func (rp *RecordPage) getFloat64(slot int, fieldName string) float64 {
+	return rp.page.getFloat64(rp.slotpos(slot) + rp.layout.offset(fieldName))
+}
+
+func (rp *RecordPage) setFloat64(slot int, fieldName string, val float64) {
+	rp.page.setFloat64(rp.slotpos(slot)+rp.layout.offset(fieldName), val)
+}
+
+// getTimestamp/setTimestamp store a TIMESTAMP field as a Unix timestamp,
+// matching the width Layout reserves for it (see fieldLengthInBytes).
+func (rp *RecordPage) getTimestamp(slot int, fieldName string) int64 {
+	return rp.getInt64(slot, fieldName)
+}
+
+func (rp *RecordPage) setTimestamp(slot int, fieldName string, val int64) {
+	rp.setInt64(slot, fieldName, val)
+}
+
+func (rp *RecordPage) flag(slot int) int32 {
+	return rp.page.getInt(rp.slotpos(slot))
+}
+
+func (rp *RecordPage) setFlag(slot int, flag int32) {
+	rp.page.setInt(rp.slotpos(slot), flag)
+}
+
+// insertAfter returns the slot number of the next EMPTY slot after slot,
+// marking it USED, or -1 if there isn't one.
+func (rp *RecordPage) insertAfter(slot int) int {
+	newslot := rp.searchAfter(slot, flagEmpty)
+	if newslot >= 0 {
+		rp.setFlag(newslot, flagUsed)
+	}
+	return newslot
+}
+
+// nextUsedSlot returns the slot number of the next USED slot after slot,
+// or -1 if there isn't one.
+func (rp *RecordPage) nextUsedSlot(slot int) int {
+	return rp.searchAfter(slot, flagUsed)
+}
+
+// delete marks slot EMPTY so a later insertAfter can reuse it.
+func (rp *RecordPage) delete(slot int) {
+	rp.setFlag(slot, flagEmpty)
+}
+
+func (rp *RecordPage) searchAfter(slot int, flag int32) int {
+	slot++
+	for rp.isValidSlot(slot) {
+		if rp.flag(slot) == flag {
+			return slot
+		}
+		slot++
+	}
+	return -1
+}
+
+func (rp *RecordPage) isValidSlot(slot int) bool {
+	return rp.slotpos(slot+1) <= int32(len(rp.page.buf))
+}
@@ -0,0 +1,79 @@
+package main
+
+// FieldType enumerates the value types a Schema field can hold.
+type FieldType int
+
+const (
+	INT32 FieldType = iota
+	VARCHAR
+	BOOL
+	INT64
+	FLOAT64
+	TIMESTAMP
+)
+
+// field describes one column of a Schema: its type, and for VARCHAR its
+// declared maximum length in bytes.
+type field struct {
+	fieldType FieldType
+	length    int // only meaningful for VARCHAR
+}
+
+// Schema is an ordered collection of named, typed fields. It's the
+// description a Layout computes byte offsets from.
+type Schema struct {
+	fields []string
+	info   map[string]field
+}
+
+func newSchema() *Schema {
+	return &Schema{info: make(map[string]field)}
+}
+
+func (s *Schema) addField(name string, fieldType FieldType, length int) {
+	s.fields = append(s.fields, name)
+	s.info[name] = field{fieldType: fieldType, length: length}
+}
+
+func (s *Schema) addInt32Field(name string) {
+	s.addField(name, INT32, 0)
+}
+
+func (s *Schema) addInt64Field(name string) {
+	s.addField(name, INT64, 0)
+}
+
+func (s *Schema) addFloat64Field(name string) {
+	s.addField(name, FLOAT64, 0)
+}
+
+func (s *Schema) addBoolField(name string) {
+	s.addField(name, BOOL, 0)
+}
+
+func (s *Schema) addTimestampField(name string) {
+	s.addField(name, TIMESTAMP, 0)
+}
+
+// addStringField declares name as a VARCHAR field able to hold up to
+// length ASCII bytes.
+func (s *Schema) addStringField(name string, length int) {
+	s.addField(name, VARCHAR, length)
+}
+
+func (s *Schema) fieldNames() []string {
+	return s.fields
+}
+
+func (s *Schema) hasField(name string) bool {
+	_, ok := s.info[name]
+	return ok
+}
+
+func (s *Schema) fieldType(name string) FieldType {
+	return s.info[name].fieldType
+}
+
+func (s *Schema) length(name string) int {
+	return s.info[name].length
+}